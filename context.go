@@ -0,0 +1,135 @@
+package grest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+)
+
+var errNoRenderer = errors.New("grest: Context is not bound to a Router with a Renderer set")
+
+// Renderer renders the template named name with data onto w. Router.Renderer
+// holds one, so the template engine a given app uses (html/template, a
+// third-party engine, ...) can be swapped without grest depending on it.
+type Renderer interface {
+	Render(w io.Writer, name string, data interface{}, c *Context) error
+}
+
+// Context is the per-request handle passed to reflect-adapted handlers
+// (func(*Context) error and friends, see HandlerProvider). It wraps the
+// underlying (res, req, next) triple with the helpers handlers reach for
+// most: reading params/body, and writing a response.
+type Context struct {
+	Res  http.ResponseWriter
+	Req  *http.Request
+	Next Next
+
+	// Router is set when the Context was built by a HandlerProvider wired
+	// to a Router via Router.Handle; it's what Render uses to reach
+	// Router.Renderer. It's nil for a Context built from a bare
+	// AdaptHandler(fn) that was never bound to a Router.
+	Router *Router
+}
+
+// URLParam returns the named path parameter captured while matching this
+// request's route (e.g. "id" for a route registered as "/users/:id"). It
+// reads from the params the router stashed on the request's context, kept
+// separate from the query string, so it can't be shadowed by a
+// same-named query parameter.
+func (this *Context) URLParam(name string) string {
+	return pathParams(this.Req).Get(name)
+}
+
+// QueryParam returns the named query string parameter.
+func (this *Context) QueryParam(name string) string {
+	return this.Req.URL.Query().Get(name)
+}
+
+// Bind decodes the request body as JSON into v.
+func (this *Context) Bind(v interface{}) error {
+	body, err := ioutil.ReadAll(this.Req.Body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// JSON writes v to the response as JSON with the given status code.
+func (this *Context) JSON(code int, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	this.Res.Header().Set("Content-Type", "application/json")
+	this.Res.WriteHeader(code)
+	_, err = this.Res.Write(data)
+	return err
+}
+
+// String writes s to the response as plain text with the given status code.
+func (this *Context) String(code int, s string) error {
+	this.Res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	this.Res.WriteHeader(code)
+	_, err := this.Res.Write([]byte(s))
+	return err
+}
+
+// Redirect replies with a redirect to url using the given status code.
+func (this *Context) Redirect(code int, url string) error {
+	http.Redirect(this.Res, this.Req, url, code)
+	return nil
+}
+
+// Render renders the template named name with data through Router.Renderer
+// and writes it to the response with the given status code. It returns an
+// error if this Context isn't bound to a Router with a Renderer set - see
+// Router.Handle. Like JSON/String, it does all fallible work - here,
+// rendering into a buffer - before touching the ResponseWriter, so a
+// Renderer error can still reach Router.ErrorHandler and set its own
+// status instead of the header already being committed to code.
+func (this *Context) Render(code int, name string, data interface{}) error {
+	if this.Router == nil || this.Router.Renderer == nil {
+		return errNoRenderer
+	}
+
+	var buf bytes.Buffer
+	if err := this.Router.Renderer.Render(&buf, name, data, this); err != nil {
+		return err
+	}
+
+	this.Res.WriteHeader(code)
+	_, err := this.Res.Write(buf.Bytes())
+	return err
+}
+
+// stripPort returns hostport's host, or hostport unchanged if it doesn't
+// carry a port (net.SplitHostPort errors on a bare host or IP).
+func stripPort(hostport string) string {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return hostport
+	}
+	return host
+}
+
+// RealIP returns the client's IP, honoring X-Forwarded-For/X-Real-IP ahead
+// of RemoteAddr and stripping the port.
+func (this *Context) RealIP() string {
+	if forwarded := this.Req.Header.Get("X-Forwarded-For"); forwarded != "" {
+		if idx := strings.IndexByte(forwarded, ','); idx != -1 {
+			forwarded = forwarded[:idx]
+		}
+		return stripPort(strings.TrimSpace(forwarded))
+	}
+
+	if realIP := this.Req.Header.Get("X-Real-IP"); realIP != "" {
+		return stripPort(strings.TrimSpace(realIP))
+	}
+
+	return stripPort(this.Req.RemoteAddr)
+}
@@ -0,0 +1,120 @@
+package grest
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestContextRealIP(t *testing.T) {
+	cases := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{"remote addr only", "203.0.113.5:51234", nil, "203.0.113.5"},
+		{"x-forwarded-for single", "203.0.113.5:51234", map[string]string{"X-Forwarded-For": "198.51.100.7"}, "198.51.100.7"},
+		{"x-forwarded-for list takes first", "203.0.113.5:51234", map[string]string{"X-Forwarded-For": "198.51.100.7, 10.0.0.1"}, "198.51.100.7"},
+		{"x-real-ip", "203.0.113.5:51234", map[string]string{"X-Real-IP": "198.51.100.9"}, "198.51.100.9"},
+		{"x-forwarded-for with port", "203.0.113.5:51234", map[string]string{"X-Forwarded-For": "198.51.100.7:443"}, "198.51.100.7"},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = c.remoteAddr
+		for k, v := range c.headers {
+			req.Header.Set(k, v)
+		}
+
+		ctx := &Context{Req: req}
+		if got := ctx.RealIP(); got != c.want {
+			t.Errorf("%s: RealIP() = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+type stubRenderer struct{}
+
+func (stubRenderer) Render(w io.Writer, name string, data interface{}, c *Context) error {
+	_, err := w.Write([]byte("rendered:" + name))
+	return err
+}
+
+func TestContextRenderUsesRouterRenderer(t *testing.T) {
+	router := NewRouter()
+	router.Renderer = stubRenderer{}
+	router.GET("/home", func(c *Context) error {
+		return c.Render(http.StatusOK, "home", nil)
+	})
+
+	req := httptest.NewRequest("GET", "/home", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if got := rec.Body.String(); got != "rendered:home" {
+		t.Errorf("body = %q, want %q", got, "rendered:home")
+	}
+}
+
+type failingRenderer struct{ err error }
+
+func (r failingRenderer) Render(w io.Writer, name string, data interface{}, c *Context) error {
+	return r.err
+}
+
+func TestContextRenderErrorReachesErrorHandler(t *testing.T) {
+	router := NewRouter()
+	renderErr := errors.New("template broke")
+	router.Renderer = failingRenderer{err: renderErr}
+	router.ErrorHandler = func(c *Context, err error) {
+		c.String(http.StatusTeapot, "handled: "+err.Error())
+	}
+	router.GET("/home", func(c *Context) error {
+		return c.Render(http.StatusOK, "home", nil)
+	})
+
+	req := httptest.NewRequest("GET", "/home", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusTeapot {
+		t.Errorf("status = %d, want %d: Render's code must not be committed before the Renderer error is known", rec.Code, http.StatusTeapot)
+	}
+	if want := "handled: " + renderErr.Error(); rec.Body.String() != want {
+		t.Errorf("body = %q, want %q", rec.Body.String(), want)
+	}
+}
+
+func TestContextRenderWithoutRendererErrors(t *testing.T) {
+	ctx := &Context{Res: httptest.NewRecorder(), Req: httptest.NewRequest("GET", "/", nil)}
+	if err := ctx.Render(http.StatusOK, "home", nil); err == nil {
+		t.Fatal("Render without a Router/Renderer set should return an error")
+	}
+}
+
+func TestRouterErrorHandlerWiring(t *testing.T) {
+	router := NewRouter()
+	sentinel := errors.New("boom")
+	var gotErr error
+	router.ErrorHandler = func(c *Context, err error) {
+		gotErr = err
+		c.String(http.StatusTeapot, "handled")
+	}
+	router.GET("/fail", func(res http.ResponseWriter, req *http.Request, next Next) {
+		next(sentinel)
+	})
+
+	req := httptest.NewRequest("GET", "/fail", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if gotErr != sentinel {
+		t.Errorf("ErrorHandler err = %v, want %v", gotErr, sentinel)
+	}
+	if rec.Code != http.StatusTeapot {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusTeapot)
+	}
+}
@@ -0,0 +1,148 @@
+package grest
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CORSPolicy configures how a Router or a single Route answers cross-origin
+// requests: which origins/methods/headers a preflight is allowed to
+// negotiate, and which headers decorate the simple-request response that
+// follows. Set via Router.CORS for a router-wide default, or Route.CORS to
+// override it for one route.
+type CORSPolicy struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           int // seconds; <= 0 omits Access-Control-Max-Age
+
+	// AllowOriginFunc, when set, decides whether origin is allowed instead
+	// of consulting AllowedOrigins.
+	AllowOriginFunc func(origin string) bool
+}
+
+// CORS sets policy as this router's default CORS policy, applied to every
+// route that doesn't set its own via Route.CORS.
+func (this *Router) CORS(policy CORSPolicy) *Router {
+	this.corsPolicy = &policy
+	return this
+}
+
+// resolveCORSPolicy returns route's own policy if it set one via Route.CORS,
+// else this router's default (nil if neither did).
+func (this *Router) resolveCORSPolicy(route *Route) *CORSPolicy {
+	if route != nil && route.corsPolicy != nil {
+		return route.corsPolicy
+	}
+	return this.corsPolicy
+}
+
+// corsPolicyForPath resolves the CORS policy that would apply at path, used
+// answering a preflight before a concrete method/route has been chosen: the
+// first registered route at that path with its own policy wins, falling
+// back to this router's default.
+func (this *Router) corsPolicyForPath(path string) *CORSPolicy {
+	if m := this.tree.findNode(path); m.node != nil {
+		for _, route := range m.node.routes {
+			if route.corsPolicy != nil {
+				return route.corsPolicy
+			}
+		}
+	}
+	return this.corsPolicy
+}
+
+// allowOrigin reports whether origin may receive Access-Control-* headers
+// under policy, and the value to echo back as Access-Control-Allow-Origin.
+func (policy *CORSPolicy) allowOrigin(origin string) (string, bool) {
+	if origin == "" {
+		return "", false
+	}
+
+	if policy.AllowOriginFunc != nil {
+		if !policy.AllowOriginFunc(origin) {
+			return "", false
+		}
+		return origin, true
+	}
+
+	for _, allowed := range policy.AllowedOrigins {
+		if allowed == "*" {
+			// a wildcard can't be paired with credentials - browsers reject
+			// Access-Control-Allow-Origin: * alongside
+			// Access-Control-Allow-Credentials: true - so echo the concrete
+			// origin instead of "*" whenever AllowCredentials is set.
+			if policy.AllowCredentials {
+				return origin, true
+			}
+			return "*", true
+		}
+		if allowed == origin {
+			return origin, true
+		}
+	}
+
+	return "", false
+}
+
+// writeSimpleHeaders attaches the Access-Control-* headers a "simple"
+// (non-preflight) CORS response carries, if origin is allowed under policy.
+func (policy *CORSPolicy) writeSimpleHeaders(res http.ResponseWriter, origin string) {
+	allowOrigin, ok := policy.allowOrigin(origin)
+	if !ok {
+		return
+	}
+
+	header := res.Header()
+	header.Set("Access-Control-Allow-Origin", allowOrigin)
+	if policy.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if len(policy.ExposedHeaders) > 0 {
+		header.Set("Access-Control-Expose-Headers", strings.Join(policy.ExposedHeaders, ", "))
+	}
+}
+
+// preflight answers an OPTIONS request carrying an Origin header as a CORS
+// preflight: it negotiates Access-Control-Allow-Methods from allowed (the
+// union of methods registered for the request path, collected by
+// node.allowedMethods from each Route's optionsMethods) and Access-Control-Allow-Headers from the
+// requested Access-Control-Request-Headers, then short-circuits with a 204
+// without reaching any downstream handler.
+func (policy *CORSPolicy) preflight(res http.ResponseWriter, req *http.Request, allowed []string) {
+	origin := req.Header.Get("Origin")
+	allowOrigin, ok := policy.allowOrigin(origin)
+	if !ok {
+		res.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	methods := allowed
+	if len(policy.AllowedMethods) > 0 {
+		methods = policy.AllowedMethods
+	}
+
+	header := res.Header()
+	header.Set("Access-Control-Allow-Origin", allowOrigin)
+	header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+
+	if requested := req.Header.Get("Access-Control-Request-Headers"); requested != "" {
+		if len(policy.AllowedHeaders) > 0 {
+			header.Set("Access-Control-Allow-Headers", strings.Join(policy.AllowedHeaders, ", "))
+		} else {
+			header.Set("Access-Control-Allow-Headers", requested)
+		}
+	}
+
+	if policy.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if policy.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(policy.MaxAge))
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,96 @@
+package grest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCORSPreflight(t *testing.T) {
+	router := NewRouter()
+	router.CORS(CORSPolicy{
+		AllowedOrigins: []string{"https://example.com"},
+		AllowedHeaders: []string{"Content-Type"},
+		MaxAge:         600,
+	})
+	router.GET("/widgets", HTTPHandleFunc(noopHandler))
+
+	req := httptest.NewRequest("OPTIONS", "/widgets", nil)
+	req.Header.Set("Origin", "https://example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	req.Header.Set("Access-Control-Request-Headers", "Content-Type")
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://example.com" {
+		t.Errorf("Allow-Origin = %q, want %q", got, "https://example.com")
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Methods"); !strings.Contains(got, "GET") {
+		t.Errorf("Allow-Methods = %q, want it to contain GET", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type" {
+		t.Errorf("Allow-Headers = %q, want %q", got, "Content-Type")
+	}
+	if got := rec.Header().Get("Access-Control-Max-Age"); got != "600" {
+		t.Errorf("Max-Age = %q, want %q", got, "600")
+	}
+}
+
+func TestCORSSimpleRequestHeaders(t *testing.T) {
+	router := NewRouter()
+	router.CORS(CORSPolicy{
+		AllowedOrigins:   []string{"https://example.com"},
+		ExposedHeaders:   []string{"X-Total-Count"},
+		AllowCredentials: true,
+	})
+	router.GET("/widgets", HTTPHandleFunc(noopHandler))
+
+	req := httptest.NewRequest("GET", "/widgets", nil)
+	req.Header.Set("Origin", "https://example.com")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://example.com" {
+		t.Errorf("Allow-Origin = %q, want %q", got, "https://example.com")
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("Allow-Credentials = %q, want %q", got, "true")
+	}
+	if got := rec.Header().Get("Access-Control-Expose-Headers"); got != "X-Total-Count" {
+		t.Errorf("Expose-Headers = %q, want %q", got, "X-Total-Count")
+	}
+}
+
+func TestCORSWildcardOriginNeverPairedWithCredentials(t *testing.T) {
+	policy := &CORSPolicy{AllowedOrigins: []string{"*"}, AllowCredentials: true}
+
+	origin, ok := policy.allowOrigin("https://attacker.example")
+	if !ok {
+		t.Fatal("allowOrigin: want the origin allowed")
+	}
+	if origin != "https://attacker.example" {
+		t.Errorf("allowOrigin = %q, want the concrete origin echoed back, not \"*\"", origin)
+	}
+}
+
+func TestCORSRoutePolicyOverridesRouterDefault(t *testing.T) {
+	router := NewRouter()
+	router.CORS(CORSPolicy{AllowedOrigins: []string{"https://default.example"}})
+	router.Route("/widgets").GET(HTTPHandleFunc(noopHandler)).CORS(CORSPolicy{
+		AllowedOrigins: []string{"https://widgets.example"},
+	})
+
+	req := httptest.NewRequest("GET", "/widgets", nil)
+	req.Header.Set("Origin", "https://default.example")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Allow-Origin = %q, want empty: route's own policy doesn't allow the router's default origin", got)
+	}
+}
@@ -0,0 +1,136 @@
+package grest
+
+// Group is a lightweight view onto a Router that joins prefix to every
+// path it registers and runs middleware ahead of them, scoped to that
+// prefix. It shares the parent Router's layer stack, so a Group's routes
+// and middleware are ordered exactly as if they'd been registered on the
+// Router directly - Group is sugar over Router.Use/Router.Route, not a
+// separate routing mechanism.
+type Group struct {
+	router *Router
+	prefix string
+}
+
+// Group creates a route group under prefix. middleware, if any, is
+// registered via Use so it only runs for requests under prefix; groups
+// are nestable (api := r.Group("/api"); v1 := api.Group("/v1", authMw)).
+// middleware may be HTTPHandler values or funcs of almost any signature,
+// same as Router.Use.
+func (this *Router) Group(prefix string, middleware ...interface{}) *Group {
+	group := &Group{router: this, prefix: prefix}
+
+	if len(middleware) > 0 {
+		this.Use(prefix, middleware...)
+	}
+
+	return group
+}
+
+// Group creates a nested group under this.prefix+prefix.
+func (this *Group) Group(prefix string, middleware ...interface{}) *Group {
+	return this.router.Group(this.prefix+prefix, middleware...)
+}
+
+// Use registers handlers for this.prefix+path; an empty path registers
+// them for the group's prefix itself. handlers may be HTTPHandler values or funcs of almost any signature, same as
+// Router.Use.
+func (this *Group) Use(path string, handlers ...interface{}) *Group {
+	this.router.Use(this.prefix+path, handlers...)
+	return this
+}
+
+// UseFunc registers handler functions for this.prefix+path; an empty path
+// registers them for the group's prefix itself.
+func (this *Group) UseFunc(path string, handlers ...HTTPHandleFunc) *Group {
+	this.router.UseFunc(this.prefix+path, handlers...)
+	return this
+}
+
+// Route creates a sub-route at this.prefix+path.
+func (this *Group) Route(path string) *Route {
+	return this.router.Route(this.prefix + path)
+}
+
+// All sets handlers for all types of requests on this.prefix+path.
+// handlers may be HTTPHandler values or funcs of almost any signature,
+// same as Router.Use.
+func (this *Group) All(path string, handlers ...interface{}) *Group {
+	this.router.All(this.prefix+path, handlers...)
+	return this
+}
+
+// AllFunc sets handler functions for all types of requests on this.prefix+path.
+func (this *Group) AllFunc(path string, handlers ...HTTPHandleFunc) *Group {
+	this.router.AllFunc(this.prefix+path, handlers...)
+	return this
+}
+
+// GET sets handlers for `GET` requests on this.prefix+path. handlers may
+// be HTTPHandler values or funcs of almost any signature, same as
+// Router.Use.
+func (this *Group) GET(path string, handlers ...interface{}) *Group {
+	this.router.GET(this.prefix+path, handlers...)
+	return this
+}
+
+// POST sets handlers for `POST` requests on this.prefix+path. handlers
+// may be HTTPHandler values or funcs of almost any signature, same as
+// Router.Use.
+func (this *Group) POST(path string, handlers ...interface{}) *Group {
+	this.router.POST(this.prefix+path, handlers...)
+	return this
+}
+
+// PUT sets handlers for `PUT` requests on this.prefix+path. handlers may
+// be HTTPHandler values or funcs of almost any signature, same as
+// Router.Use.
+func (this *Group) PUT(path string, handlers ...interface{}) *Group {
+	this.router.PUT(this.prefix+path, handlers...)
+	return this
+}
+
+// DELETE sets handlers for `DELETE` requests on this.prefix+path.
+// handlers may be HTTPHandler values or funcs of almost any signature,
+// same as Router.Use.
+func (this *Group) DELETE(path string, handlers ...interface{}) *Group {
+	this.router.DELETE(this.prefix+path, handlers...)
+	return this
+}
+
+// HEAD sets handlers for `HEAD` requests on this.prefix+path. handlers
+// may be HTTPHandler values or funcs of almost any signature, same as
+// Router.Use.
+func (this *Group) HEAD(path string, handlers ...interface{}) *Group {
+	this.router.HEAD(this.prefix+path, handlers...)
+	return this
+}
+
+// GETFunc sets handler functions for `GET` requests on this.prefix+path.
+func (this *Group) GETFunc(path string, handlers ...HTTPHandleFunc) *Group {
+	this.router.GETFunc(this.prefix+path, handlers...)
+	return this
+}
+
+// POSTFunc sets handler functions for `POST` requests on this.prefix+path.
+func (this *Group) POSTFunc(path string, handlers ...HTTPHandleFunc) *Group {
+	this.router.POSTFunc(this.prefix+path, handlers...)
+	return this
+}
+
+// PUTFunc sets handler functions for `PUT` requests on this.prefix+path.
+func (this *Group) PUTFunc(path string, handlers ...HTTPHandleFunc) *Group {
+	this.router.PUTFunc(this.prefix+path, handlers...)
+	return this
+}
+
+// DELETEFunc sets handler functions for `DELETE` requests on this.prefix+path.
+func (this *Group) DELETEFunc(path string, handlers ...HTTPHandleFunc) *Group {
+	this.router.DELETEFunc(this.prefix+path, handlers...)
+	return this
+}
+
+// HEADFunc sets handler functions for `HEAD` requests on this.prefix+path.
+func (this *Group) HEADFunc(path string, handlers ...HTTPHandleFunc) *Group {
+	this.router.HEADFunc(this.prefix+path, handlers...)
+	return this
+}
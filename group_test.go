@@ -0,0 +1,56 @@
+package grest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func recordingHandler(order *[]string, name string, callNext bool) HTTPHandleFunc {
+	return func(res http.ResponseWriter, req *http.Request, next Next) {
+		*order = append(*order, name)
+		if callNext {
+			next(nil)
+		}
+	}
+}
+
+func TestGroupNestedPrefixAndMiddlewareOrder(t *testing.T) {
+	router := NewRouter()
+	var order []string
+
+	api := router.Group("/api", recordingHandler(&order, "api-mw", true))
+	v1 := api.Group("/v1", recordingHandler(&order, "v1-mw", true))
+	v1.GET("/users/:id", recordingHandler(&order, "handler", false))
+
+	req := httptest.NewRequest("GET", "/api/v1/users/42", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	want := []string{"api-mw", "v1-mw", "handler"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("execution order = %v, want %v", order, want)
+	}
+}
+
+func TestGroupScopesMiddlewareToItsPrefix(t *testing.T) {
+	router := NewRouter()
+	var order []string
+
+	api := router.Group("/api", recordingHandler(&order, "api-mw", true))
+	api.GET("/users", recordingHandler(&order, "handler", false))
+
+	// a request outside the group's prefix must not run its middleware,
+	// nor match the route registered under it
+	req := httptest.NewRequest("GET", "/users", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if len(order) != 0 {
+		t.Errorf("execution order = %v, want none: request is outside the group's prefix", order)
+	}
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,195 @@
+package grest
+
+import (
+	"net/http"
+	"reflect"
+)
+
+var (
+	errorType    = reflect.TypeOf((*error)(nil)).Elem()
+	contextType  = reflect.TypeOf((*Context)(nil))
+	requestType  = reflect.TypeOf((*http.Request)(nil))
+	responseType = reflect.TypeOf((*http.ResponseWriter)(nil)).Elem()
+)
+
+// HandlerProvider adapts a function of almost any signature - e.g.
+// func(*Context) error, func(*http.Request, http.ResponseWriter), or
+// func(*Context, DTO) (Result, error) - into an HTTPHandler, so callers
+// don't have to hand-write the `func(res, req, next)` boilerplate
+// themselves. It implements HTTPHandler, so it can be passed anywhere a
+// handler is accepted today (Router.Use, Router.GET, ...).
+type HandlerProvider struct {
+	fn      reflect.Value
+	argPlan []argBuilder
+	outPlan outHandler
+	router  *Router // set by Router.Handle so the built Context can reach Renderer/ErrorHandler
+}
+
+// argBuilder produces the reflect.Value for one parameter of the adapted
+// function, given the request being served.
+type argBuilder func(c *Context) (reflect.Value, error)
+
+// outHandler turns the adapted function's return values into a response
+// (or an error passed to next).
+type outHandler func(c *Context, out []reflect.Value)
+
+// AdaptHandler wraps fn as an HTTPHandler. fn's signature is inspected once
+// here, at registration time, via preCheckHandler; every request then only
+// pays for building the arguments and calling fn through reflection.
+// AdaptHandler panics if fn is not a func. Its parameters may be *Context,
+// *http.Request, http.ResponseWriter, or a DTO (anything else, decoded
+// from the request body as JSON); its return values may be a trailing
+// error (passed to next) and/or one preceding value (JSON-encoded onto
+// the response) - there's no input/output type preCheckHandler rejects.
+// Only one parameter can be decoded from the body this way: the body is a
+// stream, so a second DTO parameter finds it already drained and fails to
+// bind at request time, not at registration.
+func AdaptHandler(fn interface{}) *HandlerProvider {
+	provider := &HandlerProvider{fn: reflect.ValueOf(fn)}
+	provider.preCheckHandler()
+	return provider
+}
+
+// Handle is AdaptHandler bound to this Router: the Context passed to fn
+// has Router set, so fn can reach this.Renderer (via Context.Render) and
+// benefits from this.ErrorHandler the same way any other route does.
+func (this *Router) Handle(fn interface{}) *HandlerProvider {
+	provider := AdaptHandler(fn)
+	provider.router = this
+	return provider
+}
+
+// toHTTPHandler adapts v into an HTTPHandler: a value that's already an
+// HTTPHandler (HTTPHandleFunc, *HandlerProvider, *Router, ...) passes
+// through unchanged, and any other func is run through AdaptHandler and
+// bound to this router, the same way Router.Handle binds it, so the
+// Context fn receives can reach this.Renderer/this.ErrorHandler. This is
+// what lets Router.Use/Router.GET/etc. take a handler of almost any
+// signature directly, without callers wrapping it through AdaptHandler or
+// Router.Handle themselves first.
+func (this *Router) toHTTPHandler(v interface{}) HTTPHandler {
+	switch h := v.(type) {
+	case HTTPHandler:
+		return h
+	case func(http.ResponseWriter, *http.Request, Next):
+		return HTTPHandleFunc(h)
+	default:
+		return this.Handle(v)
+	}
+}
+
+// toHTTPHandlers adapts each value in vs via toHTTPHandler.
+func (this *Router) toHTTPHandlers(vs []interface{}) []HTTPHandler {
+	handlers := make([]HTTPHandler, len(vs))
+	for i, v := range vs {
+		handlers[i] = this.toHTTPHandler(v)
+	}
+	return handlers
+}
+
+// preCheckHandler inspects fn's in/out types once and builds the argPlan/
+// outPlan closures HTTPHandle replays on every request.
+func (this *HandlerProvider) preCheckHandler() {
+	fnType := this.fn.Type()
+	if fnType.Kind() != reflect.Func {
+		panic("grest: AdaptHandler requires a func, got " + fnType.String())
+	}
+
+	this.argPlan = make([]argBuilder, fnType.NumIn())
+	for i := 0; i < fnType.NumIn(); i++ {
+		this.argPlan[i] = buildArgBuilder(fnType.In(i))
+	}
+
+	this.outPlan = buildOutHandler(fnType)
+}
+
+// buildArgBuilder decides, once per parameter type, how that argument will
+// be pulled off the request at call time.
+func buildArgBuilder(paramType reflect.Type) argBuilder {
+	switch {
+	case paramType == contextType:
+		return func(c *Context) (reflect.Value, error) {
+			return reflect.ValueOf(c), nil
+		}
+
+	case paramType == requestType:
+		return func(c *Context) (reflect.Value, error) {
+			return reflect.ValueOf(c.Req), nil
+		}
+
+	case paramType == responseType:
+		return func(c *Context) (reflect.Value, error) {
+			return reflect.ValueOf(c.Res), nil
+		}
+
+	default:
+		// anything else is treated as a DTO decoded from the request body.
+		// Only one such parameter per handler is supported: c.Bind reads
+		// Req.Body, and the second DTO parameter would find it already
+		// drained by the first.
+		isPtr := paramType.Kind() == reflect.Ptr
+		elemType := paramType
+		if isPtr {
+			elemType = paramType.Elem()
+		}
+
+		return func(c *Context) (reflect.Value, error) {
+			dto := reflect.New(elemType)
+			if err := c.Bind(dto.Interface()); err != nil {
+				return reflect.Value{}, err
+			}
+			if isPtr {
+				return dto, nil
+			}
+			return dto.Elem(), nil
+		}
+	}
+}
+
+// buildOutHandler decides, once per function signature, how the returned
+// values become a response: a trailing error always short-circuits to
+// next(err); a value ahead of it is JSON-encoded onto the response.
+func buildOutHandler(fnType reflect.Type) outHandler {
+	numOut := fnType.NumOut()
+
+	hasError := numOut > 0 && fnType.Out(numOut-1) == errorType
+	hasValue := numOut > 1 || (numOut == 1 && !hasError)
+
+	return func(c *Context, out []reflect.Value) {
+		if hasError {
+			if err, _ := out[numOut-1].Interface().(error); err != nil {
+				c.Next(err)
+				return
+			}
+		}
+
+		if hasValue {
+			if err := c.JSON(http.StatusOK, out[0].Interface()); err != nil {
+				c.Next(err)
+			}
+			return
+		}
+
+		// no value to encode: the handler already wrote its own response
+		// (or there's nothing to send), so there's nothing left to do
+	}
+}
+
+// HTTPHandle implements HTTPHandler: it builds fn's arguments for this
+// request, calls fn through reflection, and hands the results to outPlan.
+func (this *HandlerProvider) HTTPHandle(res http.ResponseWriter, req *http.Request, next Next) {
+	c := &Context{Res: res, Req: req, Next: next, Router: this.router}
+
+	args := make([]reflect.Value, len(this.argPlan))
+	for i, build := range this.argPlan {
+		v, err := build(c)
+		if err != nil {
+			next(err)
+			return
+		}
+		args[i] = v
+	}
+
+	out := this.fn.Call(args)
+	this.outPlan(c, out)
+}
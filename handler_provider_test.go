@@ -0,0 +1,67 @@
+package grest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type greetDTO struct {
+	Name string `json:"name"`
+}
+
+type greetResult struct {
+	Message string `json:"message"`
+}
+
+func TestAdaptHandlerDTORoundTrip(t *testing.T) {
+	fn := func(c *Context, body greetDTO) (greetResult, error) {
+		return greetResult{Message: "hello " + body.Name}, nil
+	}
+	provider := AdaptHandler(fn)
+
+	req := httptest.NewRequest("POST", "/greet", strings.NewReader(`{"name":"ada"}`))
+	rec := httptest.NewRecorder()
+
+	var nextErr error
+	provider.HTTPHandle(rec, req, func(err error) { nextErr = err })
+
+	if nextErr != nil {
+		t.Fatalf("next called with error: %v", nextErr)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var got greetResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if want := "hello ada"; got.Message != want {
+		t.Errorf("Message = %q, want %q", got.Message, want)
+	}
+}
+
+func TestAdaptHandlerErrorShortCircuits(t *testing.T) {
+	sentinel := errors.New("boom")
+	fn := func(c *Context) error {
+		return sentinel
+	}
+	provider := AdaptHandler(fn)
+
+	req := httptest.NewRequest("GET", "/fail", nil)
+	rec := httptest.NewRecorder()
+
+	var nextErr error
+	provider.HTTPHandle(rec, req, func(err error) { nextErr = err })
+
+	if nextErr != sentinel {
+		t.Fatalf("next error = %v, want %v", nextErr, sentinel)
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("body = %q, want empty: an errored handler shouldn't write a response", rec.Body.String())
+	}
+}
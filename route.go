@@ -0,0 +1,114 @@
+package grest
+
+import "net/http"
+
+// Route holds the handler chains registered for one path, keyed by HTTP
+// method (GET/POST on the same path are two separate *Route values sharing
+// one tree.node, see node.addRoute). Router.Route(path) returns one;
+// Router.GET/POST/etc. register a chain on it via addHandler.
+type Route struct {
+	path       string
+	handlers   map[string][]HTTPHandler
+	corsPolicy *CORSPolicy
+}
+
+// newRoute creates an empty Route for path, with no handlers registered yet.
+func newRoute(path string) *Route {
+	return &Route{path: path, handlers: make(map[string][]HTTPHandler)}
+}
+
+// CORS sets policy as this route's own CORS policy, overriding the
+// router-wide default set by Router.CORS for requests matching this route.
+func (this *Route) CORS(policy CORSPolicy) *Route {
+	this.corsPolicy = &policy
+	return this
+}
+
+// handlesMethod reports whether this route has any handlers registered for
+// method.
+func (this *Route) handlesMethod(method string) bool {
+	return len(this.handlers[method]) > 0
+}
+
+// optionsMethods returns every method this route answers, used to build the
+// Allow header of a 405/OPTIONS response.
+func (this *Route) optionsMethods() []string {
+	methods := make([]string, 0, len(this.handlers))
+	for method := range this.handlers {
+		methods = append(methods, method)
+	}
+	return methods
+}
+
+func (this *Route) addHandlers(method string, handlers ...HTTPHandler) *Route {
+	this.handlers[method] = append(this.handlers[method], handlers...)
+	return this
+}
+
+// GET registers handlers for `GET` requests on this route.
+func (this *Route) GET(handlers ...HTTPHandler) *Route {
+	return this.addHandlers("GET", handlers...)
+}
+
+// POST registers handlers for `POST` requests on this route.
+func (this *Route) POST(handlers ...HTTPHandler) *Route {
+	return this.addHandlers("POST", handlers...)
+}
+
+// PUT registers handlers for `PUT` requests on this route.
+func (this *Route) PUT(handlers ...HTTPHandler) *Route {
+	return this.addHandlers("PUT", handlers...)
+}
+
+// DELETE registers handlers for `DELETE` requests on this route.
+func (this *Route) DELETE(handlers ...HTTPHandler) *Route {
+	return this.addHandlers("DELETE", handlers...)
+}
+
+// HEAD registers handlers for `HEAD` requests on this route.
+func (this *Route) HEAD(handlers ...HTTPHandler) *Route {
+	return this.addHandlers("HEAD", handlers...)
+}
+
+// allMethods lists every method All registers handlers for.
+var allMethods = []string{"GET", "POST", "PUT", "DELETE", "HEAD"}
+
+// All registers handlers for every method in allMethods.
+func (this *Route) All(handlers ...HTTPHandler) *Route {
+	for _, method := range allMethods {
+		this.addHandlers(method, handlers...)
+	}
+	return this
+}
+
+// AllFunc registers handler functions for every method in allMethods.
+func (this *Route) AllFunc(handlers ...HTTPHandleFunc) *Route {
+	for _, handler := range handlers {
+		this.All(handler)
+	}
+	return this
+}
+
+// HTTPHandle implements HTTPHandler: it runs the handler chain registered
+// for req.Method (falling back to the GET chain for HEAD, same as
+// tree.firstHandling), calling each handler in turn until one doesn't call
+// next, or the chain is exhausted and done runs.
+func (this *Route) HTTPHandle(res http.ResponseWriter, req *http.Request, done Next) {
+	handlers := this.handlers[req.Method]
+	if len(handlers) == 0 && req.Method == "HEAD" {
+		handlers = this.handlers["GET"]
+	}
+
+	idx := 0
+	var next Next
+	next = func(err error) {
+		if err != nil || idx >= len(handlers) {
+			done(err)
+			return
+		}
+		h := handlers[idx]
+		idx++
+		h.HTTPHandle(res, req, next)
+	}
+	next(nil)
+}
@@ -1,10 +1,11 @@
 package grest
 
 import (
-	"net/http"
-	"strings"
+	"context"
 	"encoding/json"
+	"net/http"
 	"net/url"
+	"strings"
 )
 
 type (
@@ -20,6 +21,42 @@ type (
 	Router struct {
 		stack        []*layer
 		routerPrefix string // prefix path, trimmed off it when route
+		tree         *node  // radix tree of registered routes, keyed by path
+
+		// RedirectTrailingSlash, when true, issues a 301 to the
+		// slash-toggled form of the path if that form has a registered
+		// route and the requested one doesn't.
+		RedirectTrailingSlash bool
+		// RedirectFixedPath, when true, issues a 301 to the case/slash
+		// folded form of the path (via cleanPath) if that form has a
+		// registered route and the requested one doesn't.
+		RedirectFixedPath bool
+		// TrimTrailingSlashes, when true, strips a trailing "/" off the
+		// request path before matching, so "/users/" and "/users" hit the
+		// same route.
+		TrimTrailingSlashes bool
+
+		// Renderer renders templates for Context.Render. Nil by default;
+		// Render returns an error until one is set.
+		Renderer Renderer
+		// ErrorHandler, when set, replaces the router's default 500
+		// response for errors returned up through next(err).
+		ErrorHandler func(c *Context, err error)
+
+		// UseRawPath, when true, matches against req.URL.EscapedPath()
+		// instead of the already percent-decoded req.URL.Path, so a
+		// segment like "foo%2Fbar" is matched as one segment rather than
+		// being split on the slash it decodes to.
+		UseRawPath bool
+		// UnescapePathValues, when true alongside UseRawPath, percent-
+		// decodes captured path parameters before handlers see them. When
+		// false, params keep whatever escaping UseRawPath matched against
+		// (e.g. "user" captures "foo%2Fbar" literally).
+		UnescapePathValues bool
+
+		// corsPolicy is the router-wide default set by Router.CORS; a
+		// route with its own policy (Route.CORS) takes precedence over it.
+		corsPolicy *CORSPolicy
 	}
 )
 
@@ -28,28 +65,79 @@ func (h HTTPHandleFunc) HTTPHandle(res http.ResponseWriter, req *http.Request, n
 	h(res, req, next)
 }
 
+// layer is one entry in Router.stack: a path registered via Router.Use,
+// the handler that runs for it, and whether the path must match exactly
+// rather than as a prefix of the request path. route is nil for every
+// Router.stack entry - Use-registered filters aren't dispatched through the
+// tree - it's kept so a layer could carry one in the future without another
+// field rename.
+type layer struct {
+	path    string
+	handler HTTPHandler
+	route   *Route
+	exact   bool
+}
+
+// newLayer builds a layer for path. exact, when true, requires path to
+// equal the request path rather than merely prefix it.
+func newLayer(path string, handler HTTPHandler, exact bool) *layer {
+	return &layer{path: path, handler: handler, exact: exact}
+}
+
+// match reports whether path is handled by l: "/" matches everything, and
+// otherwise path must equal l.path or (unless exact) be prefixed by it at a
+// path-segment boundary. Use-registered filters never capture params, so
+// the returned url.Values is always nil.
+func (l *layer) match(path string) (url.Values, bool) {
+	if l.path == "/" || path == l.path {
+		return nil, true
+	}
+
+	if l.exact {
+		return nil, false
+	}
+
+	if strings.HasPrefix(path, l.path) && path[len(l.path)] == '/' {
+		return nil, true
+	}
+
+	return nil, false
+}
+
+// handleRequest runs l's handler for req.
+func (l *layer) handleRequest(res http.ResponseWriter, req *http.Request, next Next) {
+	l.handler.HTTPHandle(res, req, next)
+}
+
 // Create one new Router
 func NewRouter() *Router {
 	router := &Router{
 		stack: make([]*layer, 0),
+		tree:  &node{},
 	}
 
 	return router
 }
 
-// set handlers for `path`, default is `/`. you can use it as filters
-func (this *Router) Use(path string, handlers ...HTTPHandler) *Router {
+// set handlers for `path`, default is `/`. you can use it as filters.
+// handlers may be HTTPHandler values or funcs of almost any signature (see
+// AdaptHandler) - each is adapted via toHTTPHandler before being stacked,
+// so callers don't have to wrap them through AdaptHandler/Router.Handle
+// themselves first.
+func (this *Router) Use(path string, handlers ...interface{}) *Router {
 	if path == "" {
 		path = "/" // default to root path
 	}
 
 	for _, handler := range handlers {
+		h := this.toHTTPHandler(handler)
+
 		// prepare router prefix path
-		if r, ok := handler.(*Router); ok == true {
+		if r, ok := h.(*Router); ok == true {
 			r.routerPrefix = this.routerPrefix + path
 		}
 
-		l := newLayer(path, handler, false)
+		l := newLayer(path, h, false)
 		l.route = nil
 		this.stack = append(this.stack, l)
 	}
@@ -70,77 +158,81 @@ func (this *Router) UseFunc(path string, handlers ...HTTPHandleFunc) *Router {
 // create a sub-route
 func (this *Router) Route(path string) *Route {
 	route := newRoute(path)
-	l := newLayer(path, route, true) // route.HTTPHandler
 
-	l.route = route
-
-	this.stack = append(this.stack, l)
+	this.tree.addRoute(path, route)
 
 	return route
 }
 
-// set handlers for all types requests
-func (this *Router)All(path string, handlers ...HTTPHandler) *Router{
-	this.Route(path).All(handlers...)
+// set handlers for all types requests. handlers may be HTTPHandler values
+// or funcs of almost any signature, same as Use.
+func (this *Router) All(path string, handlers ...interface{}) *Router {
+	this.Route(path).All(this.toHTTPHandlers(handlers)...)
 
 	return this
 }
 
 // set handlers functions for all types requests
-func (this *Router)AllFunc(path string, handlers ...HTTPHandleFunc) *Router{
+func (this *Router) AllFunc(path string, handlers ...HTTPHandleFunc) *Router {
 	this.Route(path).AllFunc(handlers...)
 
 	return this
 }
 
-func (this *Router) addHandler(method string, path string, handlers ...HTTPHandler) *Router {
+func (this *Router) addHandler(method string, path string, handlers ...interface{}) *Router {
 	route := this.Route(path)
+	adapted := this.toHTTPHandlers(handlers)
 
 	switch method {
 	case "GET":
-		route.GET(handlers...);
+		route.GET(adapted...)
 	case "POST":
-		route.POST(handlers...);
+		route.POST(adapted...)
 	case "PUT":
-		route.PUT(handlers...);
+		route.PUT(adapted...)
 	case "DELETE":
-		route.DELETE(handlers...);
+		route.DELETE(adapted...)
 	case "HEAD":
-		route.HEAD(handlers...);
-	// ignore others
+		route.HEAD(adapted...)
+		// ignore others
 	}
 	return this
 }
 
-// set handlers for `GET` request
-func (this *Router) GET(path string, handlers ...HTTPHandler) *Router {
+// set handlers for `GET` request. handlers may be HTTPHandler values or
+// funcs of almost any signature, same as Use.
+func (this *Router) GET(path string, handlers ...interface{}) *Router {
 	return this.addHandler("GET", path, handlers...)
 }
 
-// set handlers for `POST` request
-func (this *Router) POST(path string, handlers ...HTTPHandler) *Router {
+// set handlers for `POST` request. handlers may be HTTPHandler values or
+// funcs of almost any signature, same as Use.
+func (this *Router) POST(path string, handlers ...interface{}) *Router {
 	return this.addHandler("POST", path, handlers...)
 }
 
-// set handlers for `PUT` request
-func (this *Router) PUT(path string, handlers ...HTTPHandler) *Router {
+// set handlers for `PUT` request. handlers may be HTTPHandler values or
+// funcs of almost any signature, same as Use.
+func (this *Router) PUT(path string, handlers ...interface{}) *Router {
 	return this.addHandler("PUT", path, handlers...)
 }
 
-// set handlers for `DELETE` request
-func (this *Router) DELETE(path string, handlers ...HTTPHandler) *Router {
+// set handlers for `DELETE` request. handlers may be HTTPHandler values or
+// funcs of almost any signature, same as Use.
+func (this *Router) DELETE(path string, handlers ...interface{}) *Router {
 	return this.addHandler("DELETE", path, handlers...)
 }
 
-// set handlers for `HEAD` request
-func (this *Router) HEAD(path string, handlers ...HTTPHandler) *Router {
+// set handlers for `HEAD` request. handlers may be HTTPHandler values or
+// funcs of almost any signature, same as Use.
+func (this *Router) HEAD(path string, handlers ...interface{}) *Router {
 	return this.addHandler("HEAD", path, handlers...)
 }
 
 // set handlers functions for `GET` request
 func (this *Router) GETFunc(path string, handlers ...HTTPHandleFunc) *Router {
 	for _, handler := range handlers {
-		this.GET(path, handler); // pass them one by one, so that HTTPHandleFunc can be treat as HTTPHandler
+		this.GET(path, handler) // pass them one by one, so that HTTPHandleFunc can be treat as HTTPHandler
 	}
 	return this
 }
@@ -148,7 +240,7 @@ func (this *Router) GETFunc(path string, handlers ...HTTPHandleFunc) *Router {
 // set handlers functions for `POST` request
 func (this *Router) POSTFunc(path string, handlers ...HTTPHandleFunc) *Router {
 	for _, handler := range handlers {
-		this.POST(path, handler);
+		this.POST(path, handler)
 	}
 	return this
 }
@@ -156,7 +248,7 @@ func (this *Router) POSTFunc(path string, handlers ...HTTPHandleFunc) *Router {
 // set handlers functions for `PUT` request
 func (this *Router) PUTFunc(path string, handlers ...HTTPHandleFunc) *Router {
 	for _, handler := range handlers {
-		this.PUT(path, handler);
+		this.PUT(path, handler)
 	}
 	return this
 }
@@ -164,7 +256,7 @@ func (this *Router) PUTFunc(path string, handlers ...HTTPHandleFunc) *Router {
 // set handlers functions for `DELETE` request
 func (this *Router) DELETEFunc(path string, handlers ...HTTPHandleFunc) *Router {
 	for _, handler := range handlers {
-		this.DELETE(path, handler);
+		this.DELETE(path, handler)
 	}
 	return this
 }
@@ -172,17 +264,56 @@ func (this *Router) DELETEFunc(path string, handlers ...HTTPHandleFunc) *Router
 // set handlers functions for `HEAD` request
 func (this *Router) HEADFunc(path string, handlers ...HTTPHandleFunc) *Router {
 	for _, handler := range handlers {
-		this.HEAD(path, handler);
+		this.HEAD(path, handler)
 	}
 	return this
 }
 
-
 func (this *Router) matchLayer(l *layer, path string) (url.Values, bool) {
 	urlParams, match := l.match(path)
 	return urlParams, match
 }
 
+// unescapeParams percent-decodes each captured param value in place. Used
+// when UseRawPath and UnescapePathValues are both set, since params
+// captured off EscapedPath() are still percent-encoded.
+func unescapeParams(params url.Values) {
+	for _, values := range params {
+		for i, v := range values {
+			if decoded, err := url.PathUnescape(v); err == nil {
+				values[i] = decoded
+			}
+		}
+	}
+}
+
+// pathParamsKey is the request-context key captured path params are stashed
+// under by withPathParams, and read back by pathParams/Context.URLParam.
+type pathParamsKey struct{}
+
+// withPathParams returns req with params attached to its context, so a
+// handler can recover them later via pathParams. Params are kept off the
+// query string entirely: splicing them into req.URL.RawQuery would let an
+// attacker-controlled query string (e.g. "/users/:id" requested as
+// "/users/5?id=evil") shadow the route's own captured value.
+func withPathParams(req *http.Request, params url.Values) *http.Request {
+	if len(params) == 0 {
+		return req
+	}
+	return req.WithContext(context.WithValue(req.Context(), pathParamsKey{}, params))
+}
+
+// pathParams returns the path params withPathParams stashed on req, or nil
+// if this request matched no params.
+func pathParams(req *http.Request) url.Values {
+	params, _ := req.Context().Value(pathParamsKey{}).(url.Values)
+	return params
+}
+
+// route runs req through this.stack (the `Use`-registered filters, in
+// registration order) and then, once they're exhausted, resolves the
+// matching route through this.tree in O(len(path)) instead of scanning
+// every registered route.
 func (this *Router) route(req *http.Request, res http.ResponseWriter, done Next) {
 	var next func(err error)
 	var idx = 0
@@ -208,56 +339,121 @@ func (this *Router) route(req *http.Request, res http.ResponseWriter, done Next)
 	}
 
 	next = func(err error) {
-		if idx >= len(this.stack) {
-			done(err)
-			return
-		}
 		// get trimmed path for current router
-		path := strings.TrimPrefix(req.URL.Path, this.routerPrefix)
+		reqPath := req.URL.Path
+		if this.UseRawPath {
+			reqPath = req.URL.EscapedPath()
+		}
+		path := strings.TrimPrefix(reqPath, this.routerPrefix)
 		if path == "" {
 			done(err)
 			return
 		}
 
-		// find next matching layer
-		var match = false
-		var l *layer
-		var route *Route
-		var urlParams url.Values
+		if this.TrimTrailingSlashes && len(path) > 1 && strings.HasSuffix(path, "/") {
+			path = strings.TrimSuffix(path, "/")
+		}
+
+		if err != nil {
+			done(err)
+			return
+		}
 
-		for ; match != true && idx < len(this.stack); {
-			l = this.stack[idx]
-			idx ++
-			urlParams, match = this.matchLayer(l, path);
-			route = l.route
+		for idx < len(this.stack) {
+			l := this.stack[idx]
+			idx++
 
-			if match != true || route == nil {
+			urlParams, match := this.matchLayer(l, path)
+			if !match {
 				continue
 			}
-			method := req.Method
-			hasMethod := route.handlesMethod(method)
 
-			if !hasMethod && method == "OPTIONS" {
-				for _, method := range route.optionsMethods() {
-					allowOptionsMethods = append(allowOptionsMethods, method)
-				}
-			}
+			req = withPathParams(req, urlParams)
+			l.handleRequest(res, req, next)
+			return
+		}
 
-			if !hasMethod && method != "HEAD" {
-				match = false
-			}
+		this.dispatchRoute(res, req, path, &allowOptionsMethods, done)
+	}
+
+	next(nil)
+}
+
+// dispatchRoute resolves path/req.Method against this.tree and either
+// invokes the matched route, answers with a 405 (path exists, method
+// doesn't), redirects to a near-match, or falls through to done (404).
+func (this *Router) dispatchRoute(res http.ResponseWriter, req *http.Request, path string, allowOptionsMethods *[]string, done Next) {
+	method := req.Method
+	found := this.tree.getValue(method, path)
+
+	if found.route != nil {
+		if this.UseRawPath && this.UnescapePathValues {
+			unescapeParams(found.params)
 		}
+		if policy := this.resolveCORSPolicy(found.route); policy != nil {
+			policy.writeSimpleHeaders(res, req.Header.Get("Origin"))
+		}
+		req = withPathParams(req, found.params)
+		found.route.HTTPHandle(res, req, done)
+		return
+	}
 
-		if match != true || err != nil {
-			done(err)
+	allowed := this.tree.allowedMethods(path)
+
+	if method == "OPTIONS" && len(allowed) > 0 {
+		if policy := this.corsPolicyForPath(path); policy != nil && req.Header.Get("Origin") != "" {
+			policy.preflight(res, req, allowed)
 			return
 		}
-		l.registerParamsAsQuery(req, urlParams)
 
-		l.handleRequest(res, req, next)
+		*allowOptionsMethods = append(*allowOptionsMethods, allowed...)
+		done(nil)
+		return
 	}
 
-	next(nil)
+	if len(allowed) > 0 {
+		res.Header().Set("Allow", strings.Join(allowed, ","))
+		http.Error(res, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if this.tryRedirect(res, req, path, found.tsr) {
+		return
+	}
+
+	done(nil)
+}
+
+// tryRedirect issues a 301 to a near-match of path - a slash-toggled form
+// (RedirectTrailingSlash) or a cleaned-up form (RedirectFixedPath) - when
+// one of those is enabled and actually resolves to a route.
+func (this *Router) tryRedirect(res http.ResponseWriter, req *http.Request, path string, tsr bool) bool {
+	if this.RedirectTrailingSlash && tsr {
+		target := strings.TrimSuffix(path, "/")
+		if target == path {
+			target = path + "/"
+		}
+
+		http.Redirect(res, req, this.routerPrefix+target, http.StatusMovedPermanently)
+		return true
+	}
+
+	if this.RedirectFixedPath {
+		fixed := cleanPath(path)
+		if fixed != path && this.tree.getValue(req.Method, fixed).route != nil {
+			http.Redirect(res, req, this.routerPrefix+fixed, http.StatusMovedPermanently)
+			return true
+		}
+
+		if ciPath, ok := this.tree.findCaseInsensitivePath(fixed); ok {
+			if ciPath != path && this.tree.getValue(req.Method, ciPath).route != nil {
+				http.Redirect(res, req, this.routerPrefix+ciPath, http.StatusMovedPermanently)
+				return true
+			}
+		}
+	}
+
+	return false
 }
 
 // implement HTTPHandler interface, make it can be as a handler
@@ -269,6 +465,10 @@ func (this *Router) HTTPHandle(res http.ResponseWriter, req *http.Request, next
 func (this Router) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	this.route(req, rw, func(err error) {
 		if err != nil {
+			if this.ErrorHandler != nil {
+				this.ErrorHandler(&Context{Res: rw, Req: req, Router: &this}, err)
+				return
+			}
 			http.Error(rw, "Something wrong", http.StatusInternalServerError)
 			return
 		}
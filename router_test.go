@@ -0,0 +1,154 @@
+package grest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// serveCapture runs req through router and returns the URLParam value the
+// matched handler observed, or "" if nothing matched.
+func serveCapture(router *Router, req *http.Request, param string) string {
+	var captured string
+	router.GETFunc("/files/:name", func(res http.ResponseWriter, r *http.Request, next Next) {
+		captured = (&Context{Req: r}).URLParam(param)
+	})
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return captured
+}
+
+func TestUseRawPathEncodedSlash(t *testing.T) {
+	router := NewRouter()
+	router.UseRawPath = true
+
+	req := httptest.NewRequest("GET", "/files/foo%2Fbar", nil)
+	got := serveCapture(router, req, "name")
+	if want := "foo%2Fbar"; got != want {
+		t.Errorf("URLParam(name) = %q, want %q (raw, still escaped)", got, want)
+	}
+}
+
+func TestUseRawPathUnescapePathValues(t *testing.T) {
+	router := NewRouter()
+	router.UseRawPath = true
+	router.UnescapePathValues = true
+
+	req := httptest.NewRequest("GET", "/files/foo%2Fbar", nil)
+	got := serveCapture(router, req, "name")
+	if want := "foo/bar"; got != want {
+		t.Errorf("URLParam(name) = %q, want %q (decoded)", got, want)
+	}
+}
+
+func TestUseRawPathEncodedSpace(t *testing.T) {
+	router := NewRouter()
+	router.UseRawPath = true
+	router.UnescapePathValues = true
+
+	req := httptest.NewRequest("GET", "/files/hello%20world", nil)
+	got := serveCapture(router, req, "name")
+	if want := "hello world"; got != want {
+		t.Errorf("URLParam(name) = %q, want %q", got, want)
+	}
+}
+
+func TestUseRawPathMixedEncodedAndDecoded(t *testing.T) {
+	router := NewRouter()
+	router.UseRawPath = true
+	router.UnescapePathValues = true
+
+	req := httptest.NewRequest("GET", "/files/a%2Fb%20c", nil)
+	got := serveCapture(router, req, "name")
+	if want := "a/b c"; got != want {
+		t.Errorf("URLParam(name) = %q, want %q", got, want)
+	}
+}
+
+func TestWithoutUseRawPathDecodesBeforeMatch(t *testing.T) {
+	router := NewRouter()
+
+	req := httptest.NewRequest("GET", "/files/foo%2Fbar", nil)
+	got := serveCapture(router, req, "name")
+	if got != "" {
+		t.Errorf("URLParam(name) = %q, want no match: decoded path has an extra segment", got)
+	}
+}
+
+func TestRedirectTrailingSlash(t *testing.T) {
+	router := NewRouter()
+	router.RedirectTrailingSlash = true
+	router.GET("/users/", func(res http.ResponseWriter, req *http.Request, next Next) {})
+
+	req := httptest.NewRequest("GET", "/users", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusMovedPermanently {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusMovedPermanently)
+	}
+	if loc := rec.Header().Get("Location"); loc != "/users/" {
+		t.Errorf("Location = %q, want %q", loc, "/users/")
+	}
+}
+
+func TestRedirectTrailingSlashDisabled(t *testing.T) {
+	router := NewRouter()
+	router.GET("/users/", func(res http.ResponseWriter, req *http.Request, next Next) {})
+
+	req := httptest.NewRequest("GET", "/users", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d (RedirectTrailingSlash is off)", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestRedirectFixedPathCaseFold(t *testing.T) {
+	router := NewRouter()
+	router.RedirectFixedPath = true
+	router.GET("/Users", func(res http.ResponseWriter, req *http.Request, next Next) {})
+
+	req := httptest.NewRequest("GET", "/users", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusMovedPermanently {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusMovedPermanently)
+	}
+	if loc := rec.Header().Get("Location"); loc != "/Users" {
+		t.Errorf("Location = %q, want %q", loc, "/Users")
+	}
+}
+
+func TestRedirectFixedPathSlashFold(t *testing.T) {
+	router := NewRouter()
+	router.RedirectFixedPath = true
+	router.GET("/users/profile", func(res http.ResponseWriter, req *http.Request, next Next) {})
+
+	req := httptest.NewRequest("GET", "/users//profile", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusMovedPermanently {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusMovedPermanently)
+	}
+	if loc := rec.Header().Get("Location"); loc != "/users/profile" {
+		t.Errorf("Location = %q, want %q", loc, "/users/profile")
+	}
+}
+
+func TestRedirectFixedPathDisabled(t *testing.T) {
+	router := NewRouter()
+	router.GET("/Users", func(res http.ResponseWriter, req *http.Request, next Next) {})
+
+	req := httptest.NewRequest("GET", "/users", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d (RedirectFixedPath is off)", rec.Code, http.StatusNotFound)
+	}
+}
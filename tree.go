@@ -0,0 +1,470 @@
+package grest
+
+import (
+	"net/url"
+	"strings"
+)
+
+type nodeType uint8
+
+const (
+	staticNode nodeType = iota
+	paramNode
+	catchAllNode
+)
+
+// node is one edge of the router's radix tree. Each node owns a path
+// fragment shared by all of its children; children are kept sorted by the
+// first byte of their fragment (`indices`) so a lookup can branch straight
+// to the right child instead of scanning a slice.
+type node struct {
+	path      string
+	indices   string
+	children  []*node
+	nType     nodeType
+	wildChild bool
+	paramName string   // set on paramNode/catchAllNode children
+	routes    []*Route // routes registered at this exact path, in registration order
+}
+
+// addRoute registers route at path, splitting and creating nodes as needed.
+// Which HTTP methods route actually answers is decided later, by
+// Route.handlesMethod - this only has to get to the right node in
+// O(len(path)) instead of scanning every registered route. It panics if
+// path conflicts with an already registered parameter of a different name
+// at the same position.
+func (n *node) addRoute(path string, route *Route) {
+	if n.path == "" && len(n.children) == 0 && n.routes == nil {
+		n.insertChild(path, route)
+		return
+	}
+
+	n.insert(path, route)
+}
+
+func (n *node) insert(fullPath string, route *Route) {
+	path := fullPath
+	current := n
+
+walk:
+	for {
+		i := longestCommonPrefix(path, current.path)
+
+		// split current node when the new path only shares a prefix of it
+		if i < len(current.path) {
+			child := &node{
+				path:      current.path[i:],
+				indices:   current.indices,
+				children:  current.children,
+				nType:     current.nType,
+				wildChild: current.wildChild,
+				paramName: current.paramName,
+				routes:    current.routes,
+			}
+
+			current.children = []*node{child}
+			current.indices = string(current.path[i])
+			current.path = current.path[:i]
+			current.nType = staticNode
+			current.wildChild = false
+			current.paramName = ""
+			current.routes = nil
+		}
+
+		// add the remainder of the new path as a child of current
+		if i < len(path) {
+			path = path[i:]
+
+			if current.wildChild {
+				current = current.children[0]
+
+				// the wildcard only conflicts if the remaining path doesn't
+				// keep matching the same param/catch-all name (nothing may
+				// follow a catch-all)
+				if len(path) >= len(current.path) && current.path == path[:len(current.path)] &&
+					(len(path) == len(current.path) ||
+						(current.nType != catchAllNode && path[len(current.path)] == '/')) {
+					continue walk
+				}
+
+				panic("grest: '" + fullPath + "' conflicts with existing wildcard segment ':" + current.paramName + "'")
+			}
+
+			c := path[0]
+
+			for idx := 0; idx < len(current.indices); idx++ {
+				if c == current.indices[idx] {
+					current = current.children[idx]
+					continue walk
+				}
+			}
+
+			current.insertChild(path, route)
+			return
+		}
+
+		// exact match on an existing node: add this route to the ones
+		// already registered for the path (GET/POST on the same path are
+		// two separate *Route values sharing one node)
+		current.routes = append(current.routes, route)
+		return
+	}
+}
+
+// insertChild walks the remainder of path, creating static/param/catch-all
+// nodes under n as needed, and registers route at the final node.
+func (n *node) insertChild(path string, route *Route) {
+	current := n
+
+	for {
+		wildcard, i, valid := findWildcard(path)
+		if wildcard == "" {
+			current = current.appendChild(&node{path: path, nType: staticNode})
+			current.routes = append(current.routes, route)
+			return
+		}
+
+		if !valid {
+			panic("grest: only one wildcard per path segment is allowed, has: '" + wildcard + "' in '" + path + "'")
+		}
+
+		if wildcard[0] == '*' && i > 0 && path[i-1] == '/' {
+			// keep the separating slash as part of the captured value,
+			// matching the convention catch-all routes are written under
+			i--
+			wildcard = path[i:]
+		}
+
+		if i > 0 {
+			current = current.appendChild(&node{path: path[:i], nType: staticNode})
+		}
+
+		if wildcard[0] == ':' {
+			if len(current.indices) > 0 {
+				panic("grest: '" + path + "' conflicts with an existing static route registered at this path segment")
+			}
+
+			paramName := wildcard[1:]
+			child := &node{nType: paramNode, paramName: paramName, path: wildcard}
+			current.wildChild = true
+			current.children = []*node{child}
+			current = child
+
+			path = path[i+len(wildcard):]
+
+			if path == "" {
+				current.routes = append(current.routes, route)
+				return
+			}
+			// a static remainder follows the param, e.g. "/:id/edit"
+			current = current.appendChild(&node{path: "", nType: staticNode})
+			continue
+		}
+
+		// catch-all: must be the last segment of the path
+		paramName := wildcard[strings.IndexByte(wildcard, '*')+1:]
+		if i+len(wildcard) != len(path) {
+			panic("grest: catch-all routes are only allowed at the end of the path, in path '" + path + "'")
+		}
+
+		if len(current.indices) > 0 {
+			panic("grest: '" + path + "' conflicts with an existing static route registered at this path segment")
+		}
+
+		child := &node{nType: catchAllNode, paramName: paramName, path: wildcard}
+		current.wildChild = true
+		current.children = []*node{child}
+		current = child
+		current.routes = append(current.routes, route)
+		return
+	}
+}
+
+func (n *node) appendChild(child *node) *node {
+	if len(n.path) == 0 && len(n.children) == 0 {
+		*n = *child
+		return n
+	}
+
+	if child.path == "" {
+		return n
+	}
+
+	c := child.path[0]
+	for idx := 0; idx < len(n.indices); idx++ {
+		if n.indices[idx] == c {
+			return n.children[idx]
+		}
+	}
+
+	// insert at the position that keeps indices sorted by first byte
+	pos := len(n.indices)
+	for pos > 0 && n.indices[pos-1] > c {
+		pos--
+	}
+	n.indices = n.indices[:pos] + string(c) + n.indices[pos:]
+	n.children = append(n.children, nil)
+	copy(n.children[pos+1:], n.children[pos:])
+	n.children[pos] = child
+	return child
+}
+
+// result is what getValue returns: the matched route (if any), its captured
+// parameters, and whether a trailing-slash redirect would find a match.
+type result struct {
+	route  *Route
+	params url.Values
+	tsr    bool
+}
+
+// firstHandling picks the first route at a node that answers method,
+// falling back to GET for HEAD requests - the same precedence the old
+// stack scan gave registration order.
+func firstHandling(routes []*Route, method string) *Route {
+	for _, route := range routes {
+		if route.handlesMethod(method) {
+			return route
+		}
+	}
+
+	if method == "HEAD" {
+		return firstHandling(routes, "GET")
+	}
+
+	return nil
+}
+
+// nodeMatch is what findNode returns: the node registered for the request
+// path (nil if none), its captured params, and whether a trailing-slash
+// redirect would find a match.
+type nodeMatch struct {
+	node   *node
+	params url.Values
+	tsr    bool
+}
+
+// findNode walks the tree in O(len(path)), descending through static,
+// param and catch-all children, and returns the node registered for path
+// along with any params captured along the way.
+func (n *node) findNode(path string) nodeMatch {
+	params := make(url.Values)
+	current := n
+
+walk:
+	for {
+		if len(path) > len(current.path) {
+			if path[:len(current.path)] == current.path {
+				path = path[len(current.path):]
+
+				if !current.wildChild {
+					c := path[0]
+					for idx := 0; idx < len(current.indices); idx++ {
+						if current.indices[idx] == c {
+							current = current.children[idx]
+							continue walk
+						}
+					}
+
+					return nodeMatch{tsr: path == "/" && len(current.routes) > 0}
+				}
+
+				current = current.children[0]
+
+				switch current.nType {
+				case paramNode:
+					end := strings.IndexByte(path, '/')
+					if end == -1 {
+						end = len(path)
+					}
+					params.Set(current.paramName, path[:end])
+
+					if end < len(path) {
+						if len(current.children) == 0 {
+							return nodeMatch{tsr: path[end:] == "/"}
+						}
+						path = path[end:]
+						current = current.children[0]
+						continue walk
+					}
+
+					return nodeMatch{node: current, params: params}
+
+				case catchAllNode:
+					params.Set(current.paramName, path)
+					return nodeMatch{node: current, params: params}
+				}
+			}
+		} else if path == current.path {
+			return nodeMatch{node: current, params: params}
+		}
+
+		// trailing-slash mismatch: "/foo" registered, "/foo/" requested (or vice-versa)
+		if path+"/" == current.path || current.path+"/" == path {
+			return nodeMatch{tsr: len(current.routes) > 0}
+		}
+
+		return nodeMatch{}
+	}
+}
+
+// getValue looks up method+path in the tree in O(len(path)) by walking one
+// node per path segment instead of scanning every registered route.
+func (n *node) getValue(method, path string) result {
+	m := n.findNode(path)
+	if m.node == nil {
+		return result{tsr: m.tsr}
+	}
+
+	return result{route: firstHandling(m.node.routes, method), params: m.params}
+}
+
+// allowedMethods returns every method answered by routes registered at the
+// node matching path, used to build the Allow header of a 405/OPTIONS
+// response.
+func (n *node) allowedMethods(path string) []string {
+	m := n.findNode(path)
+	if m.node == nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	methods := make([]string, 0, len(m.node.routes))
+	for _, route := range m.node.routes {
+		for _, method := range route.optionsMethods() {
+			if !seen[method] {
+				seen[method] = true
+				methods = append(methods, method)
+			}
+		}
+	}
+	return methods
+}
+
+// findWildcard returns the first `:param`/`*param` segment in path, its
+// starting index, and whether it's well-formed (non-empty name, no nested
+// wildcard markers).
+func findWildcard(path string) (wildcard string, i int, valid bool) {
+	for start, c := range []byte(path) {
+		if c != ':' && c != '*' {
+			continue
+		}
+
+		valid = true
+		for end, c := range []byte(path[start+1:]) {
+			switch c {
+			case '/':
+				return path[start : start+1+end], start, valid
+			case ':', '*':
+				valid = false
+			}
+		}
+		return path[start:], start, valid
+	}
+	return "", -1, false
+}
+
+func longestCommonPrefix(a, b string) int {
+	i := 0
+	max := len(a)
+	if len(b) < max {
+		max = len(b)
+	}
+	for i < max && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// findCaseInsensitivePath walks the tree the same way findNode does, but
+// folds case when matching static segments, and returns the canonically
+// registered casing of path (built from the nodes actually walked) along
+// with whether a route-bearing node was reached. It's the other half of
+// RedirectFixedPath's "case/slash folded form": cleanPath handles slash and
+// dot-segment cleanup, this recovers from the path merely being the wrong
+// case (e.g. "/Users" requested as "/users").
+func (n *node) findCaseInsensitivePath(path string) (string, bool) {
+	var fixed strings.Builder
+	current := n
+
+walk:
+	for {
+		if len(path) < len(current.path) || !strings.EqualFold(path[:len(current.path)], current.path) {
+			return "", false
+		}
+
+		fixed.WriteString(current.path)
+		path = path[len(current.path):]
+
+		if path == "" {
+			return fixed.String(), len(current.routes) > 0
+		}
+
+		if !current.wildChild {
+			c := path[0]
+			for idx := 0; idx < len(current.indices); idx++ {
+				if strings.EqualFold(current.indices[idx:idx+1], string(c)) {
+					current = current.children[idx]
+					continue walk
+				}
+			}
+			return "", false
+		}
+
+		current = current.children[0]
+
+		switch current.nType {
+		case paramNode:
+			end := strings.IndexByte(path, '/')
+			if end == -1 {
+				end = len(path)
+			}
+			fixed.WriteString(path[:end])
+			path = path[end:]
+
+			if path == "" {
+				return fixed.String(), len(current.routes) > 0
+			}
+
+			if len(current.children) == 0 {
+				return "", false
+			}
+			current = current.children[0]
+			continue walk
+
+		case catchAllNode:
+			fixed.WriteString(path)
+			return fixed.String(), true
+		}
+
+		return "", false
+	}
+}
+
+// cleanPath folds duplicate slashes and resolves `.`/`..` elements, used by
+// RedirectFixedPath to find a near-match for a malformed request path.
+func cleanPath(path string) string {
+	if path == "" {
+		return "/"
+	}
+
+	segments := strings.Split(path, "/")
+	cleaned := make([]string, 0, len(segments))
+	for _, seg := range segments {
+		switch seg {
+		case "", ".":
+			continue
+		case "..":
+			if len(cleaned) > 0 {
+				cleaned = cleaned[:len(cleaned)-1]
+			}
+		default:
+			cleaned = append(cleaned, seg)
+		}
+	}
+
+	result := "/" + strings.Join(cleaned, "/")
+	if strings.HasSuffix(path, "/") && result != "/" {
+		result += "/"
+	}
+	return result
+}
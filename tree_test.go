@@ -0,0 +1,147 @@
+package grest
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func noopHandler(http.ResponseWriter, *http.Request, Next) {}
+
+func TestNodeGetValue(t *testing.T) {
+	tree := &node{}
+	userByID := newRoute("/users/:id")
+	userByID.GET(HTTPHandleFunc(noopHandler))
+	tree.addRoute("/users/:id", userByID)
+
+	usersList := newRoute("/users")
+	usersList.GET(HTTPHandleFunc(noopHandler))
+	tree.addRoute("/users", usersList)
+
+	cases := []struct {
+		path      string
+		wantRoute *Route
+		wantParam string
+	}{
+		{"/users", usersList, ""},
+		{"/users/42", userByID, "42"},
+		{"/unknown", nil, ""},
+	}
+
+	for _, c := range cases {
+		got := tree.getValue("GET", c.path)
+		if got.route != c.wantRoute {
+			t.Errorf("getValue(%q).route = %v, want %v", c.path, got.route, c.wantRoute)
+		}
+		if c.wantParam != "" && got.params.Get("id") != c.wantParam {
+			t.Errorf("getValue(%q).params[id] = %q, want %q", c.path, got.params.Get("id"), c.wantParam)
+		}
+	}
+}
+
+func TestNodeGetValueCatchAll(t *testing.T) {
+	tree := &node{}
+	route := newRoute("/files/*path")
+	route.GET(HTTPHandleFunc(noopHandler))
+	tree.addRoute("/files/*path", route)
+
+	got := tree.getValue("GET", "/files/a/b/c.txt")
+	if got.route != route {
+		t.Fatalf("getValue: route not matched for catch-all segment")
+	}
+	if want := "/a/b/c.txt"; got.params.Get("path") != want {
+		t.Errorf("params[path] = %q, want %q", got.params.Get("path"), want)
+	}
+}
+
+// conflictPanic runs fn and returns the recovered panic value, or nil if fn
+// didn't panic.
+func conflictPanic(fn func()) (recovered interface{}) {
+	defer func() { recovered = recover() }()
+	fn()
+	return nil
+}
+
+func TestNodeAddRouteStaticThenWildcardConflict(t *testing.T) {
+	tree := &node{}
+	tree.addRoute("/users/abc", newRoute("/users/abc"))
+
+	got := conflictPanic(func() {
+		tree.addRoute("/users/:id", newRoute("/users/:id"))
+	})
+	if got == nil {
+		t.Fatal("addRoute(\"/users/:id\") after \"/users/abc\" did not panic; the static route would be silently unreachable")
+	}
+}
+
+func TestNodeAddRouteWildcardThenStaticConflict(t *testing.T) {
+	tree := &node{}
+	tree.addRoute("/users/:id", newRoute("/users/:id"))
+
+	got := conflictPanic(func() {
+		tree.addRoute("/users/abc", newRoute("/users/abc"))
+	})
+	if got == nil {
+		t.Fatal("addRoute(\"/users/abc\") after \"/users/:id\" did not panic")
+	}
+}
+
+// buildTree and buildList populate n evenly-numbered static routes
+// ("/resource0/item" .. "/resourceN/item"), the shape that most exercises
+// the difference between the radix tree's O(len(path)) lookup and a linear
+// scan's O(n).
+func buildTree(n int) *node {
+	tree := &node{}
+	for i := 0; i < n; i++ {
+		path := "/resource" + strconv.Itoa(i) + "/item"
+		route := newRoute(path)
+		route.GET(HTTPHandleFunc(noopHandler))
+		tree.addRoute(path, route)
+	}
+	return tree
+}
+
+type linearRoute struct {
+	path  string
+	route *Route
+}
+
+func buildList(n int) []linearRoute {
+	list := make([]linearRoute, n)
+	for i := 0; i < n; i++ {
+		path := "/resource" + strconv.Itoa(i) + "/item"
+		list[i] = linearRoute{path: path, route: newRoute(path)}
+	}
+	return list
+}
+
+// linearScan finds the route registered for path the way the pre-radix-tree
+// router did: a straight scan over every registered route.
+func linearScan(list []linearRoute, path string) *Route {
+	for _, r := range list {
+		if r.path == path {
+			return r.route
+		}
+	}
+	return nil
+}
+
+func BenchmarkRadixTreeMatch(b *testing.B) {
+	tree := buildTree(500)
+	path := "/resource499/item"
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.getValue("GET", path)
+	}
+}
+
+func BenchmarkLinearScanMatch(b *testing.B) {
+	list := buildList(500)
+	path := "/resource499/item"
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		linearScan(list, path)
+	}
+}